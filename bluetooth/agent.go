@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/muka/go-bluetooth/bluez/profile/agent"
+	"github.com/sirupsen/logrus"
+)
+
+// pairingAgent implements the BlueZ Agent1 interface, answering pairing
+// prompts (PIN/passkey/confirmation) on stdin so that bctl can pair with
+// devices that require more than "just works" pairing.
+type pairingAgent struct {
+	// autoConfirm answers yes/no and display-passkey prompts without
+	// touching stdin, for use with --yes.
+	autoConfirm bool
+	// passkey, when set, is returned for any PIN/passkey request instead
+	// of prompting.
+	passkey string
+
+	in *bufio.Reader
+}
+
+// agentObjectPath is the fixed DBus object path bctl registers its pairing
+// agent under.
+const agentObjectPath = "/tools/bctl/agent"
+
+func newPairingAgent(autoConfirm bool, passkey string) *pairingAgent {
+	return &pairingAgent{
+		autoConfirm: autoConfirm,
+		passkey:     passkey,
+		in:          bufio.NewReader(os.Stdin),
+	}
+}
+
+// Path returns the DBus object path this agent is exposed under.
+func (a *pairingAgent) Path() dbus.ObjectPath {
+	return dbus.ObjectPath(agentObjectPath)
+}
+
+// Interface returns the DBus interface this agent implements.
+func (a *pairingAgent) Interface() string {
+	return agent.Agent1Interface
+}
+
+// prompt reads a line of stdin for label. It never consults a.passkey,
+// since it's also used for yes/no confirmation prompts where a configured
+// passkey value would never match.
+func (a *pairingAgent) prompt(label string) (string, error) {
+	fmt.Printf("%s: ", label)
+	line, err := a.in.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("read %s from stdin: %w", label, err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// passkeyPrompt returns a.passkey if one was configured with --passkey,
+// falling back to prompting on stdin otherwise.
+func (a *pairingAgent) passkeyPrompt(label string) (string, error) {
+	if a.passkey != "" {
+		return a.passkey, nil
+	}
+	return a.prompt(label)
+}
+
+func (a *pairingAgent) confirm(label string) *dbus.Error {
+	if a.autoConfirm {
+		logrus.Infof("auto-confirming: %s", label)
+		return nil
+	}
+
+	answer, err := a.prompt(label + " (yes/no)")
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	if !strings.EqualFold(answer, "yes") && !strings.EqualFold(answer, "y") {
+		return dbus.MakeFailedError(fmt.Errorf("rejected by user"))
+	}
+	return nil
+}
+
+func (a *pairingAgent) Release() *dbus.Error {
+	logrus.Debug("pairing agent released")
+	return nil
+}
+
+func (a *pairingAgent) RequestPinCode(device dbus.ObjectPath) (string, *dbus.Error) {
+	pin, err := a.passkeyPrompt(fmt.Sprintf("enter PIN code for %s", device))
+	if err != nil {
+		logrus.WithError(err).Error("request pin code")
+		return "", dbus.MakeFailedError(err)
+	}
+	return pin, nil
+}
+
+func (a *pairingAgent) DisplayPinCode(device dbus.ObjectPath, pincode string) *dbus.Error {
+	logrus.Infof("pin code for %s: %s", device, pincode)
+	return nil
+}
+
+func (a *pairingAgent) RequestPasskey(device dbus.ObjectPath) (uint32, *dbus.Error) {
+	raw, err := a.passkeyPrompt(fmt.Sprintf("enter passkey for %s", device))
+	if err != nil {
+		logrus.WithError(err).Error("request passkey")
+		return 0, dbus.MakeFailedError(err)
+	}
+
+	var passkey uint32
+	if _, err := fmt.Sscanf(raw, "%d", &passkey); err != nil {
+		return 0, dbus.MakeFailedError(fmt.Errorf("parse passkey %q: %w", raw, err))
+	}
+	return passkey, nil
+}
+
+func (a *pairingAgent) DisplayPasskey(device dbus.ObjectPath, passkey uint32, entered uint16) *dbus.Error {
+	logrus.Infof("passkey for %s: %06d (%d digits entered)", device, passkey, entered)
+	return nil
+}
+
+func (a *pairingAgent) RequestConfirmation(device dbus.ObjectPath, passkey uint32) *dbus.Error {
+	return a.confirm(fmt.Sprintf("confirm passkey %06d for %s", passkey, device))
+}
+
+func (a *pairingAgent) RequestAuthorization(device dbus.ObjectPath) *dbus.Error {
+	return a.confirm(fmt.Sprintf("authorize pairing with %s", device))
+}
+
+func (a *pairingAgent) AuthorizeService(device dbus.ObjectPath, uuid string) *dbus.Error {
+	return a.confirm(fmt.Sprintf("authorize service %s on %s", uuid, device))
+}
+
+func (a *pairingAgent) Cancel() *dbus.Error {
+	logrus.Warn("pairing request cancelled by remote device")
+	return nil
+}
+
+// registerAgent exposes a pairingAgent as the default BlueZ pairing agent
+// over the system bus, using the capability and passkey override currently
+// set on cli (see the --agent-capability, --passkey and --yes flags).
+func (cli *Bctl) registerAgent() error {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return fmt.Errorf("connect to system bus: %w", err)
+	}
+
+	pa := newPairingAgent(cli.autoConfirm, cli.passkey)
+	if err := agent.ExposeAgent(conn, pa, cli.agentCapability, true); err != nil {
+		return fmt.Errorf("expose pairing agent: %w", err)
+	}
+
+	cli.pairingAgent = pa
+	logrus.WithField("capability", cli.agentCapability).Info("pairing agent registered")
+	return nil
+}
+
+// unregisterAgent removes the pairing agent registered by registerAgent, if
+// any.
+func (cli *Bctl) unregisterAgent() {
+	if cli.pairingAgent == nil {
+		return
+	}
+	if err := agent.RemoveAgent(cli.pairingAgent); err != nil {
+		logrus.WithError(err).Warn("remove pairing agent")
+	}
+	cli.pairingAgent = nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	cliapp "github.com/urfave/cli/v2"
+)
+
+// devicesCommand inspects the known-devices database populated by
+// scan/connect/watch, independently of the adapter (it never touches
+// BlueZ, so it works even with the adapter powered off).
+func (cli *Bctl) devicesCommand() *cliapp.Command {
+	var storePath string
+
+	pathFlag := &cliapp.StringFlag{
+		Name:        "store-path",
+		Usage:       "path to the known-devices database (default: $XDG_DATA_HOME/bctl/devices.json)",
+		Destination: &storePath,
+	}
+
+	open := func() (*Store, error) {
+		path := storePath
+		if path == "" {
+			var err error
+			path, err = defaultStorePath()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return openStore(path)
+	}
+
+	return &cliapp.Command{
+		Name:  "devices",
+		Usage: "inspect the known-devices database populated by scan/connect/watch",
+		Subcommands: []*cliapp.Command{
+			{
+				Name:  "list",
+				Usage: "list every known device",
+				Flags: []cliapp.Flag{pathFlag},
+				Action: func(ctx *cliapp.Context) error {
+					store, err := open()
+					if err != nil {
+						return err
+					}
+
+					for _, d := range store.List() {
+						fmt.Printf("%-20s %-25s last seen %s\n", d.MAC, d.Name, d.LastSeen.Format(time.RFC3339))
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "show",
+				Usage:     "show everything known about a device",
+				ArgsUsage: "<mac>",
+				Flags:     []cliapp.Flag{pathFlag},
+				Action: func(ctx *cliapp.Context) error {
+					mac := ctx.Args().First()
+					if mac == "" {
+						return fmt.Errorf("usage: devices show <mac>")
+					}
+
+					store, err := open()
+					if err != nil {
+						return err
+					}
+
+					d, ok := store.Get(mac)
+					if !ok {
+						return fmt.Errorf("device %s not known", mac)
+					}
+
+					fmt.Printf("mac:        %s\n", d.MAC)
+					fmt.Printf("name:       %s\n", d.Name)
+					fmt.Printf("services:   %s\n", strings.Join(d.Services, ", "))
+					fmt.Printf("first seen: %s\n", d.FirstSeen.Format(time.RFC3339))
+					fmt.Printf("last seen:  %s\n", d.LastSeen.Format(time.RFC3339))
+					fmt.Println("rssi history:")
+					for _, sample := range d.RSSIHistory {
+						fmt.Printf("  %s %d\n", sample.At.Format(time.RFC3339), sample.RSSI)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "forget",
+				Usage:     "remove a device from the database",
+				ArgsUsage: "<mac>",
+				Flags:     []cliapp.Flag{pathFlag},
+				Action: func(ctx *cliapp.Context) error {
+					mac := ctx.Args().First()
+					if mac == "" {
+						return fmt.Errorf("usage: devices forget <mac>")
+					}
+
+					store, err := open()
+					if err != nil {
+						return err
+					}
+					return store.Forget(mac)
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/muka/go-bluetooth/api"
+	"github.com/muka/go-bluetooth/bluez/profile/adapter"
+	"github.com/muka/go-bluetooth/bluez/profile/device"
+	"github.com/sirupsen/logrus"
+)
+
+// DeviceEventKind describes why a DeviceEvent was emitted.
+type DeviceEventKind int
+
+const (
+	// DeviceFound is emitted the first time a device path is observed.
+	DeviceFound DeviceEventKind = iota
+	// DeviceUpdated is emitted on subsequent property changes for a
+	// device path already seen.
+	DeviceUpdated
+	// DeviceLost is emitted when a previously-seen device can no longer
+	// be read from BlueZ (it went out of range or was removed).
+	DeviceLost
+)
+
+// DeviceEvent is a single observation of a device during discovery, shared
+// by every subcommand that consumes the discovery stream (scan, connect,
+// watch).
+type DeviceEvent struct {
+	Kind             DeviceEventKind
+	Path             dbus.ObjectPath
+	Address          string
+	Name             string
+	RSSI             int16
+	ManufacturerData map[uint16]interface{}
+	Services         []string
+}
+
+// watchDevices starts BlueZ discovery with the given filter and turns the
+// raw stream of discovered object paths into a stream of typed DeviceEvent
+// values, tracking which paths have already been seen so callers can tell
+// a newly-found device from a property update on one they already know
+// about.
+func (cli *Bctl) watchDevices(filter *adapter.DiscoveryFilter) (<-chan DeviceEvent, func(), error) {
+	raw, cancel, err := api.Discover(cli.adapter, filter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("discover devices: %w", err)
+	}
+
+	events := make(chan DeviceEvent)
+	seen := make(map[dbus.ObjectPath]struct{})
+
+	go func() {
+		defer close(events)
+
+		for gotDev := range raw {
+			logrus.Trace("scanned device", gotDev.Path)
+
+			d, err := device.NewDevice1(gotDev.Path)
+			if err != nil {
+				logrus.WithError(err).Trace("create device by dbus path")
+				continue
+			}
+
+			ev, err := deviceEvent(d)
+			if err != nil {
+				if _, ok := seen[gotDev.Path]; ok {
+					delete(seen, gotDev.Path)
+					events <- DeviceEvent{Kind: DeviceLost, Path: gotDev.Path}
+				}
+				continue
+			}
+
+			if _, ok := seen[gotDev.Path]; ok {
+				ev.Kind = DeviceUpdated
+			} else {
+				ev.Kind = DeviceFound
+				seen[gotDev.Path] = struct{}{}
+			}
+
+			if cli.store != nil {
+				if err := cli.store.Upsert(ev); err != nil {
+					logrus.WithError(err).Warn("persist discovered device")
+				}
+			}
+
+			events <- ev
+		}
+	}()
+
+	return events, cancel, nil
+}
+
+// deviceEvent reads the current properties of d and converts them to a
+// DeviceEvent. The Kind field is left at its zero value (DeviceFound) for
+// the caller to adjust.
+func deviceEvent(d *device.Device1) (DeviceEvent, error) {
+	addr, err := d.GetAddress()
+	if err != nil {
+		return DeviceEvent{}, fmt.Errorf("get device address: %w", err)
+	}
+
+	props, err := d.GetProperties()
+	if err != nil {
+		return DeviceEvent{}, fmt.Errorf("get device properties: %w", err)
+	}
+
+	return DeviceEvent{
+		Path:             d.Path(),
+		Address:          addr,
+		Name:             props.Name,
+		RSSI:             props.RSSI,
+		ManufacturerData: props.ManufacturerData,
+		Services:         props.UUIDs,
+	}, nil
+}
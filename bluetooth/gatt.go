@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/muka/go-bluetooth/bluez/profile/device"
+	"github.com/muka/go-bluetooth/bluez/profile/gatt"
+)
+
+// Service is a GATT service discovered under a connected device, along
+// with its characteristics.
+type Service struct {
+	UUID            string
+	Path            dbus.ObjectPath
+	Characteristics []Characteristic
+}
+
+// Characteristic is a GATT characteristic discovered under a Service.
+type Characteristic struct {
+	UUID  string
+	Path  dbus.ObjectPath
+	Flags []string
+}
+
+// GATT walks the BlueZ object tree under a connected device and exposes its
+// GATT services/characteristics for reading, writing and subscribing to.
+type GATT struct {
+	device *device.Device1
+}
+
+func newGATT(d *device.Device1) *GATT {
+	return &GATT{device: d}
+}
+
+// Services walks the BlueZ object tree under the device path and resolves
+// every GattService1/GattCharacteristic1 object beneath it.
+func (g *GATT) Services() ([]Service, error) {
+	paths, err := listManagedObjectPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	devicePrefix := string(g.device.Path()) + "/"
+
+	services := make(map[dbus.ObjectPath]*Service)
+	var order []dbus.ObjectPath
+	charsByService := make(map[dbus.ObjectPath][]Characteristic)
+
+	for _, path := range paths {
+		if !strings.HasPrefix(string(path), devicePrefix) {
+			continue
+		}
+
+		if svc, err := gatt.NewGattService1(path); err == nil {
+			props, err := svc.GetProperties()
+			if err != nil {
+				continue
+			}
+			services[path] = &Service{UUID: props.UUID, Path: path}
+			order = append(order, path)
+			continue
+		}
+
+		if ch, err := gatt.NewGattCharacteristic1(path); err == nil {
+			props, err := ch.GetProperties()
+			if err != nil {
+				continue
+			}
+			charsByService[props.Service] = append(charsByService[props.Service], Characteristic{
+				UUID:  props.UUID,
+				Path:  path,
+				Flags: props.Flags,
+			})
+		}
+	}
+
+	sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+
+	result := make([]Service, 0, len(order))
+	for _, path := range order {
+		svc := services[path]
+		svc.Characteristics = charsByService[path]
+		result = append(result, *svc)
+	}
+	return result, nil
+}
+
+func (g *GATT) findCharPath(uuid string) (dbus.ObjectPath, error) {
+	services, err := g.Services()
+	if err != nil {
+		return "", err
+	}
+
+	for _, svc := range services {
+		for _, ch := range svc.Characteristics {
+			if strings.EqualFold(ch.UUID, uuid) {
+				return ch.Path, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("characteristic %s not found", uuid)
+}
+
+// ReadChar reads the current value of the characteristic with the given
+// UUID.
+func (g *GATT) ReadChar(uuid string) ([]byte, error) {
+	path, err := g.findCharPath(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := gatt.NewGattCharacteristic1(path)
+	if err != nil {
+		return nil, fmt.Errorf("open characteristic %s: %w", uuid, err)
+	}
+
+	value, err := ch.ReadValue(map[string]interface{}{})
+	if err != nil {
+		return nil, fmt.Errorf("read characteristic %s: %w", uuid, err)
+	}
+	return value, nil
+}
+
+// WriteChar writes data to the characteristic with the given UUID, with or
+// without a write response.
+func (g *GATT) WriteChar(uuid string, data []byte, withResponse bool) error {
+	path, err := g.findCharPath(uuid)
+	if err != nil {
+		return err
+	}
+
+	ch, err := gatt.NewGattCharacteristic1(path)
+	if err != nil {
+		return fmt.Errorf("open characteristic %s: %w", uuid, err)
+	}
+
+	writeType := "command"
+	if withResponse {
+		writeType = "request"
+	}
+
+	if err := ch.WriteValue(data, map[string]interface{}{"type": writeType}); err != nil {
+		return fmt.Errorf("write characteristic %s: %w", uuid, err)
+	}
+	return nil
+}
+
+// Subscribe enables notifications on the characteristic with the given
+// UUID and streams decoded values until the returned cancel func is called
+// or the underlying property watch ends. Callers must call cancel when
+// they're done, or BlueZ will keep the characteristic in "notifying" state
+// after the process exits.
+func (g *GATT) Subscribe(uuid string) (values <-chan []byte, cancel func(), err error) {
+	path, err := g.findCharPath(uuid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ch, err := gatt.NewGattCharacteristic1(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open characteristic %s: %w", uuid, err)
+	}
+
+	if err := ch.StartNotify(); err != nil {
+		return nil, nil, fmt.Errorf("start notify on %s: %w", uuid, err)
+	}
+
+	propChanges, err := ch.WatchProperties()
+	if err != nil {
+		ch.StopNotify()
+		return nil, nil, fmt.Errorf("watch properties on %s: %w", uuid, err)
+	}
+
+	out := make(chan []byte)
+	go func() {
+		defer close(out)
+		for change := range propChanges {
+			if change.Interface != "org.bluez.GattCharacteristic1" || change.Name != "Value" {
+				continue
+			}
+			value, ok := change.Value.([]byte)
+			if !ok {
+				continue
+			}
+			out <- value
+		}
+	}()
+
+	cancel = func() {
+		ch.UnwatchProperties(propChanges)
+		ch.StopNotify()
+	}
+
+	return out, cancel, nil
+}
+
+// listManagedObjectPaths returns every object path known to BlueZ's object
+// manager, sorted for deterministic iteration.
+func listManagedObjectPaths() ([]dbus.ObjectPath, error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("connect to system bus: %w", err)
+	}
+
+	var managed map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	om := conn.Object("org.bluez", dbus.ObjectPath("/"))
+	if err := om.Call("org.freedesktop.DBus.ObjectManager.GetManagedObjects", 0).Store(&managed); err != nil {
+		return nil, fmt.Errorf("get managed objects: %w", err)
+	}
+
+	paths := make([]dbus.ObjectPath, 0, len(managed))
+	for path := range managed {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+	return paths, nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/signal"
+
+	cliapp "github.com/urfave/cli/v2"
+)
+
+// connectForGATT runs the usual discover+connect flow and then resolves the
+// GATT tree under the connected device, so every gatt subcommand can assume
+// cli.gatt is ready to use.
+func (cli *Bctl) connectForGATT(ctx *cliapp.Context) error {
+	if err := cli.Init(); err != nil {
+		return err
+	}
+
+	cancel, err := cli.Discover(ctx)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	if err := cli.Wait(ctx.Context); err != nil {
+		return err
+	}
+
+	if err := cli.Connect(ctx); err != nil {
+		return err
+	}
+
+	cli.gatt = newGATT(cli.connectedDevice)
+	return nil
+}
+
+func (cli *Bctl) gattCommand() *cliapp.Command {
+	return &cliapp.Command{
+		Name:   "gatt",
+		Usage:  "inspect and use GATT services on the connected device",
+		Before: cli.connectForGATT,
+		After: func(ctx *cliapp.Context) error {
+			cli.unregisterAgent()
+			return nil
+		},
+		Subcommands: []*cliapp.Command{
+			cli.gattListCommand(),
+			cli.gattReadCommand(),
+			cli.gattWriteCommand(),
+			cli.gattNotifyCommand(),
+		},
+	}
+}
+
+func (cli *Bctl) gattListCommand() *cliapp.Command {
+	return &cliapp.Command{
+		Name:  "list",
+		Usage: "list GATT services and characteristics on the connected device",
+		Action: func(ctx *cliapp.Context) error {
+			services, err := cli.gatt.Services()
+			if err != nil {
+				return err
+			}
+
+			for _, svc := range services {
+				fmt.Println(svc.UUID)
+				for _, ch := range svc.Characteristics {
+					fmt.Printf("  %s %v\n", ch.UUID, ch.Flags)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func (cli *Bctl) gattReadCommand() *cliapp.Command {
+	return &cliapp.Command{
+		Name:      "read",
+		Usage:     "read a characteristic's current value",
+		ArgsUsage: "<char-uuid>",
+		Action: func(ctx *cliapp.Context) error {
+			uuid := ctx.Args().First()
+			if uuid == "" {
+				return fmt.Errorf("usage: gatt read <char-uuid>")
+			}
+
+			value, err := cli.gatt.ReadChar(uuid)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(hex.EncodeToString(value))
+			return nil
+		},
+	}
+}
+
+func (cli *Bctl) gattWriteCommand() *cliapp.Command {
+	var withoutResponse bool
+
+	return &cliapp.Command{
+		Name:      "write",
+		Usage:     "write a hex-encoded value to a characteristic",
+		ArgsUsage: "<char-uuid> <hex>",
+		Flags: []cliapp.Flag{
+			&cliapp.BoolFlag{
+				Name:        "without-response",
+				Usage:       "write without waiting for a response",
+				Destination: &withoutResponse,
+			},
+		},
+		Action: func(ctx *cliapp.Context) error {
+			uuid := ctx.Args().Get(0)
+			rawHex := ctx.Args().Get(1)
+			if uuid == "" || rawHex == "" {
+				return fmt.Errorf("usage: gatt write <char-uuid> <hex>")
+			}
+
+			data, err := hex.DecodeString(rawHex)
+			if err != nil {
+				return fmt.Errorf("decode hex value: %w", err)
+			}
+
+			return cli.gatt.WriteChar(uuid, data, !withoutResponse)
+		},
+	}
+}
+
+func (cli *Bctl) gattNotifyCommand() *cliapp.Command {
+	return &cliapp.Command{
+		Name:      "notify",
+		Usage:     "subscribe to a characteristic and print values until interrupted",
+		ArgsUsage: "<char-uuid>",
+		Action: func(ctx *cliapp.Context) error {
+			uuid := ctx.Args().First()
+			if uuid == "" {
+				return fmt.Errorf("usage: gatt notify <char-uuid>")
+			}
+
+			values, cancel, err := cli.gatt.Subscribe(uuid)
+			if err != nil {
+				return err
+			}
+			defer cancel()
+
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, os.Interrupt)
+			defer signal.Stop(sigCh)
+
+			for {
+				select {
+				case <-sigCh:
+					return nil
+				case value, ok := <-values:
+					if !ok {
+						return nil
+					}
+					fmt.Println(hex.EncodeToString(value))
+				}
+			}
+		},
+	}
+}
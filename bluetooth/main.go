@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,54 +12,165 @@ import (
 	"github.com/urfave/cli/v2"
 	"tinygo.org/x/bluetooth"
 
-	"github.com/muka/go-bluetooth/api"
 	"github.com/muka/go-bluetooth/bluez/profile/adapter"
+	"github.com/muka/go-bluetooth/bluez/profile/agent"
 	"github.com/muka/go-bluetooth/bluez/profile/device"
 )
 
 type flags struct {
-	MAC   *cli.StringFlag
-	Debug *cli.BoolFlag
+	MAC             *cli.StringSliceFlag
+	DiscoverTimeout *cli.DurationFlag
+	Debug           *cli.BoolFlag
 	// ConnectionTimeout *cli.DurationFlag
+
+	AgentCapability *cli.StringFlag
+	Passkey         *cli.StringFlag
+	Yes             *cli.BoolFlag
+
+	Name      *cli.StringFlag
+	NoStore   *cli.BoolFlag
+	StorePath *cli.StringFlag
 }
 
 func (f *flags) Set() []cli.Flag {
 	return []cli.Flag{
 		f.MAC,
+		f.DiscoverTimeout,
 		f.Debug,
 		// f.ConnectionTimeout,
+		f.AgentCapability,
+		f.Passkey,
+		f.Yes,
+		f.Name,
+		f.NoStore,
+		f.StorePath,
 	}
 }
 
+// deviceState tracks discovery/pairing/connection progress for a single
+// target MAC, so Discover and Connect can drive many devices at once.
+type deviceState struct {
+	mac        bluetooth.MAC
+	discovered bool
+	paired     bool
+	connected  bool
+	lastErr    error
+	device     *device.Device1
+}
+
 type Bctl struct {
-	flags     flags
-	deviceMAC bluetooth.MAC
+	flags   flags
+	targets map[bluetooth.MAC]*deviceState
+
+	discoverTimeout time.Duration
 
 	adapter *adapter.Adapter1
 
 	discoveryDone <-chan struct{}
+
+	agentCapability string
+	passkey         string
+	autoConfirm     bool
+	pairingAgent    *pairingAgent
+
+	// connectedDevice is set to the most recently connected target, for
+	// subcommands (like gatt) that operate on a single device.
+	connectedDevice *device.Device1
+	gatt            *GATT
+
+	// powerCycleAdapter is set by watch's --power-cycle-adapter flag.
+	powerCycleAdapter bool
+
+	nameQuery string
+	noStore   bool
+	storePath string
+	store     *Store
+}
+
+// addTarget registers mac as a device to discover and connect to, if it
+// isn't already tracked.
+func (cli *Bctl) addTarget(mac bluetooth.MAC) {
+	if cli.targets == nil {
+		cli.targets = make(map[bluetooth.MAC]*deviceState)
+	}
+	if _, ok := cli.targets[mac]; ok {
+		return
+	}
+	cli.targets[mac] = &deviceState{mac: mac}
 }
 
 func main() {
 	var tool Bctl
 	f := flags{
-		MAC: &cli.StringFlag{
+		MAC: &cli.StringSliceFlag{
 			Name:  "mac",
-			Usage: "MAC adress of device connect to",
-			Action: func(ctx *cli.Context, s string) error {
-				mac, err := bluetooth.ParseMAC(s)
-				if err != nil {
-					return fmt.Errorf("incorrect mac address: %w", err)
+			Usage: "MAC address(es) of devices to connect to (comma-separated and/or repeatable)",
+			Action: func(ctx *cli.Context, vals []string) error {
+				for _, raw := range vals {
+					for _, s := range strings.Split(raw, ",") {
+						s = strings.TrimSpace(s)
+						if s == "" {
+							continue
+						}
+
+						mac, err := bluetooth.ParseMAC(s)
+						if err != nil {
+							return fmt.Errorf("incorrect mac address %q: %w", s, err)
+						}
+						tool.addTarget(mac)
+					}
 				}
-				tool.deviceMAC = mac
 				return nil
 			},
 		},
 
+		DiscoverTimeout: &cli.DurationFlag{
+			Name:        "discover-timeout",
+			Usage:       "give up discovery after this long even if not every --mac target was seen (0 = no timeout)",
+			Destination: &tool.discoverTimeout,
+		},
+
 		Debug: &cli.BoolFlag{
 			Name:   "debug",
 			Hidden: true,
 		},
+
+		AgentCapability: &cli.StringFlag{
+			Name:        "agent-capability",
+			Usage:       "IO capability advertised by the pairing agent (KeyboardDisplay, DisplayOnly, KeyboardOnly, NoInputNoOutput, DisplayYesNo)",
+			Value:       agent.CapKeyboardDisplay,
+			Destination: &tool.agentCapability,
+		},
+
+		Passkey: &cli.StringFlag{
+			Name:        "passkey",
+			Usage:       "passkey/PIN to answer pairing requests with, instead of prompting on stdin",
+			Destination: &tool.passkey,
+		},
+
+		Yes: &cli.BoolFlag{
+			Name:        "yes",
+			Usage:       "auto-confirm passkey/authorization prompts instead of asking on stdin",
+			Destination: &tool.autoConfirm,
+		},
+
+		Name: &cli.StringFlag{
+			Name:        "name",
+			Usage:       "connect to a cached device whose name contains this substring, instead of specifying --mac",
+			Destination: &tool.nameQuery,
+		},
+
+		NoStore: &cli.BoolFlag{
+			Name:        "no-store",
+			Usage:       "don't persist discovered devices to the known-devices database",
+			Destination: &tool.noStore,
+		},
+
+		StorePath: &cli.StringFlag{
+			Name:        "store-path",
+			Usage:       "path to the known-devices database (default: $XDG_DATA_HOME/bctl/devices.json)",
+			Destination: &tool.storePath,
+		},
 	}
 
 	app := cli.App{
@@ -72,6 +184,7 @@ func main() {
 			if err := tool.Init(); err != nil {
 				return err
 			}
+			defer tool.unregisterAgent()
 
 			cancel, err := tool.Discover(ctx)
 			if err != nil {
@@ -89,6 +202,12 @@ func main() {
 			return nil
 		},
 		Flags: f.Set(),
+		Commands: []*cli.Command{
+			tool.scanCommand(),
+			tool.gattCommand(),
+			tool.watchCommand(),
+			tool.devicesCommand(),
+		},
 	}
 
 	app.RunAndExitOnError()
@@ -100,15 +219,67 @@ func (cli *Bctl) Init() error {
 	if err != nil {
 		return fmt.Errorf("get default adapter: %w", err)
 	}
+
+	if !cli.noStore {
+		path := cli.storePath
+		if path == "" {
+			path, err = defaultStorePath()
+			if err != nil {
+				return fmt.Errorf("resolve device store path: %w", err)
+			}
+		}
+
+		cli.store, err = openStore(path)
+		if err != nil {
+			return fmt.Errorf("open device store: %w", err)
+		}
+	}
+
+	if cli.nameQuery != "" {
+		if err := cli.resolveNameTarget(); err != nil {
+			return err
+		}
+	}
+
+	if err := cli.registerAgent(); err != nil {
+		return fmt.Errorf("register pairing agent: %w", err)
+	}
+
 	return nil
 }
 
+// resolveNameTarget looks up cli.nameQuery in the known-devices database
+// and, if it uniquely identifies a cached device, adds its MAC as a
+// connect target (so --name can be used instead of --mac).
+func (cli *Bctl) resolveNameTarget() error {
+	if cli.store == nil {
+		return fmt.Errorf("--name requires the device store, remove --no-store")
+	}
+
+	matches := cli.store.FindByName(cli.nameQuery)
+	switch len(matches) {
+	case 0:
+		return fmt.Errorf("no cached device matches --name %q; run scan first or use --mac", cli.nameQuery)
+	case 1:
+		mac, err := bluetooth.ParseMAC(matches[0].MAC)
+		if err != nil {
+			return fmt.Errorf("parse cached mac %q: %w", matches[0].MAC, err)
+		}
+		cli.addTarget(mac)
+		return nil
+	default:
+		return fmt.Errorf("--name %q matches %d cached devices, be more specific or use --mac", cli.nameQuery, len(matches))
+	}
+}
+
+// Discover runs discovery until every target MAC has been seen, the
+// --discover-timeout elapses, or the context is cancelled.
 func (cli *Bctl) Discover(ctx *cli.Context) (_ context.CancelFunc, err error) {
 	filter := adapter.NewDiscoveryFilter()
 	// filter.Transport = adapter.DiscoveryFilterTransportBrEdr
-	devices, cancel, err := api.Discover(cli.adapter, &filter)
+	events, cancel, err := cli.watchDevices(&filter)
 	if err != nil {
-		return nil, fmt.Errorf("discover devices: %w", err)
+		return nil, err
 	}
 
 	done := make(chan struct{})
@@ -122,27 +293,44 @@ func (cli *Bctl) Discover(ctx *cli.Context) (_ context.CancelFunc, err error) {
 		}
 	}()
 
+	var deadline <-chan time.Time
+	if cli.discoverTimeout > 0 {
+		deadline = time.After(cli.discoverTimeout)
+	}
+
 	go func() {
 		defer close(done)
 		defer cancelOnce()
 
 		logrus.Info("discovery started")
 
-		for gotDev := range devices {
-			logrus.Trace("scanned device", gotDev.Path)
-			d, err := device.NewDevice1(gotDev.Path)
-			if err != nil {
-				logrus.WithError(err).Trace("create device by dbus path")
-				continue
-			}
-			deviceAddr, err := d.GetAddress()
-			if err != nil {
-				logrus.WithError(err).Trace("get device address failed")
-				continue
-			}
+		for {
+			select {
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				if ev.Kind == DeviceLost {
+					continue
+				}
+
+				mac, err := bluetooth.ParseMAC(ev.Address)
+				if err != nil {
+					continue
+				}
+
+				if state, ok := cli.targets[mac]; ok && !state.discovered {
+					state.discovered = true
+					logrus.WithField("mac", mac).Info("target device found")
+				}
 
-			if deviceAddr == cli.deviceMAC.String() {
-				logrus.Infof("expected device found")
+				if cli.allDiscovered() {
+					return
+				}
+			case <-deadline:
+				logrus.Warn("discovery timed out before every target device was seen")
+				return
+			case <-ctx.Done():
 				return
 			}
 		}
@@ -151,6 +339,15 @@ func (cli *Bctl) Discover(ctx *cli.Context) (_ context.CancelFunc, err error) {
 	return cancelOnce, err
 }
 
+func (cli *Bctl) allDiscovered() bool {
+	for _, state := range cli.targets {
+		if !state.discovered {
+			return false
+		}
+	}
+	return true
+}
+
 func (cli *Bctl) Wait(ctx context.Context) error {
 	select {
 	case <-ctx.Done():
@@ -165,36 +362,82 @@ var (
 	errAlreadyConnected = errors.New("Already Paired")
 )
 
+// Connect fans out an independent retry loop per target device and waits
+// for all of them to either connect or give up because the context was
+// cancelled, then prints a final status summary.
 func (cli *Bctl) Connect(ctx *cli.Context) error {
-	const retryInterval = 3 * time.Second
-	tick := time.NewTicker(retryInterval)
-	defer tick.Stop()
-	once := make(chan struct{}, 1)
-	once <- struct{}{}
-
 	adapterID, err := cli.adapter.GetAdapterID()
 	if err != nil {
 		return fmt.Errorf("get adapter id: %w", err)
 	}
 
+	var wg sync.WaitGroup
+	for _, state := range cli.targets {
+		wg.Add(1)
+		go func(state *deviceState) {
+			defer wg.Done()
+			cli.connectWithRetry(ctx.Context, adapterID, state)
+		}(state)
+	}
+	wg.Wait()
+
+	cli.printConnectSummary()
+
+	for _, state := range cli.targets {
+		if !state.connected {
+			return fmt.Errorf("failed to connect to %s: %w", state.mac, state.lastErr)
+		}
+	}
+	return nil
+}
+
+func (cli *Bctl) connectWithRetry(ctx context.Context, adapterID string, state *deviceState) {
+	const (
+		initialBackoff = 1 * time.Second
+		maxBackoff     = 30 * time.Second
+	)
+	backoff := initialBackoff
+
 	for {
+		if err := ctx.Err(); err != nil {
+			state.lastErr = err
+			return
+		}
+
+		err := cli.connect(adapterID, state)
+		if err == nil {
+			return
+		}
+
+		state.lastErr = err
+		logrus.WithError(err).WithField("mac", state.mac).WithField("retry", backoff).Info("try to connect")
+
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
-		case <-tick.C:
-		case <-once:
+			state.lastErr = ctx.Err()
+			return
+		case <-time.After(backoff):
 		}
 
-		if err := cli.connect(adapterID); err != nil {
-			logrus.WithError(err).WithField("retry", retryInterval).Info("try to connect")
-		} else {
-			return nil
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
 		}
 	}
 }
 
-func (cli *Bctl) connect(adapterID string) error {
-	d, err := device.NewDevice(adapterID, cli.deviceMAC.String())
+func (cli *Bctl) printConnectSummary() {
+	for _, state := range cli.targets {
+		if state.connected {
+			logrus.WithField("mac", state.mac).Info("connect summary: connected")
+			continue
+		}
+		logrus.WithField("mac", state.mac).WithError(state.lastErr).Warn("connect summary: failed")
+	}
+}
+
+func (cli *Bctl) connect(adapterID string, state *deviceState) error {
+	d, err := device.NewDevice(adapterID, state.mac.String())
 	if err != nil {
 		return fmt.Errorf("get device mac: %w", err)
 	}
@@ -208,11 +451,16 @@ func (cli *Bctl) connect(adapterID string) error {
 			return fmt.Errorf("pair with device: %w", err)
 		}
 	}
+	state.paired = true
 
 	if err := d.Connect(); err != nil {
 		return fmt.Errorf("connect to device: %w", err)
 	}
 
-	logrus.Info("device connected successfully")
+	state.device = d
+	state.connected = true
+	cli.connectedDevice = d
+
+	logrus.WithField("mac", state.mac).Info("device connected successfully")
 	return nil
 }
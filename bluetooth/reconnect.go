@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os/exec"
+	"time"
+
+	"github.com/muka/go-bluetooth/bluez/profile/adapter"
+	"github.com/sirupsen/logrus"
+)
+
+// Reconnector drives a single target's connect/reconnect loop off BlueZ
+// PropertiesChanged signals instead of a fixed poll interval, so a
+// disconnect (or an adapter going down) is noticed immediately rather than
+// after up to a few seconds of polling.
+type Reconnector struct {
+	cli   *Bctl
+	state *deviceState
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	jitter     float64
+}
+
+func newReconnector(cli *Bctl, state *deviceState, minBackoff, maxBackoff time.Duration, jitter float64) *Reconnector {
+	return &Reconnector{
+		cli:        cli,
+		state:      state,
+		minBackoff: minBackoff,
+		maxBackoff: maxBackoff,
+		jitter:     jitter,
+	}
+}
+
+// Run connects to the target and then blocks, reconnecting with
+// exponential backoff every time the device disconnects or the adapter
+// drops out, until ctx is cancelled.
+func (r *Reconnector) Run(ctx context.Context) error {
+	backoff := r.minBackoff
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		adapterID, err := r.cli.adapter.GetAdapterID()
+		if err != nil {
+			if err := r.recoverAdapter(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !r.state.connected {
+			if err := r.cli.connect(adapterID, r.state); err != nil {
+				logrus.WithError(err).WithField("mac", r.state.mac).WithField("retry", backoff).Warn("reconnect failed")
+
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(r.withJitter(backoff)):
+				}
+
+				backoff = r.nextBackoff(backoff)
+				continue
+			}
+		}
+
+		backoff = r.minBackoff
+
+		reason, err := r.waitForDisconnect(ctx)
+		if err != nil {
+			logrus.WithError(err).WithField("mac", r.state.mac).Warn("lost track of device, recovering adapter")
+			r.state.connected = false
+			if err := r.recoverAdapter(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+		if reason == disconnectNone {
+			return ctx.Err()
+		}
+
+		logrus.WithField("mac", r.state.mac).Warn("device disconnected, reconnecting")
+		r.state.connected = false
+	}
+}
+
+func (r *Reconnector) nextBackoff(cur time.Duration) time.Duration {
+	next := cur * 2
+	if next > r.maxBackoff {
+		next = r.maxBackoff
+	}
+	return next
+}
+
+func (r *Reconnector) withJitter(d time.Duration) time.Duration {
+	if r.jitter <= 0 {
+		return d
+	}
+	delta := time.Duration(rand.Float64() * r.jitter * float64(d))
+	return d + delta
+}
+
+type disconnectReason int
+
+const (
+	disconnectNone disconnectReason = iota
+	disconnectDevice
+)
+
+// waitForDisconnect blocks until the device's Connected property flips to
+// false (disconnectDevice), ctx is cancelled (disconnectNone), or the
+// property watch itself fails, which usually means the adapter went away.
+// It also watches the adapter's Powered property, returning an error as
+// soon as it drops, and logs changes to Discovering for visibility.
+func (r *Reconnector) waitForDisconnect(ctx context.Context) (disconnectReason, error) {
+	deviceChanges, err := r.state.device.WatchProperties()
+	if err != nil {
+		return disconnectNone, fmt.Errorf("watch device properties: %w", err)
+	}
+	defer r.state.device.UnwatchProperties(deviceChanges)
+
+	adapterChanges, err := r.cli.adapter.WatchProperties()
+	if err != nil {
+		return disconnectNone, fmt.Errorf("watch adapter properties: %w", err)
+	}
+	defer r.cli.adapter.UnwatchProperties(adapterChanges)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return disconnectNone, nil
+
+		case change, ok := <-deviceChanges:
+			if !ok {
+				return disconnectNone, fmt.Errorf("device property watch closed")
+			}
+			if change.Interface != "org.bluez.Device1" || change.Name != "Connected" {
+				continue
+			}
+			if connected, ok := change.Value.(bool); ok && !connected {
+				return disconnectDevice, nil
+			}
+
+		case change, ok := <-adapterChanges:
+			if !ok {
+				return disconnectNone, fmt.Errorf("adapter property watch closed")
+			}
+			if change.Interface != "org.bluez.Adapter1" {
+				continue
+			}
+			switch change.Name {
+			case "Powered":
+				if powered, ok := change.Value.(bool); ok && !powered {
+					return disconnectNone, fmt.Errorf("adapter powered off")
+				}
+			case "Discovering":
+				if discovering, ok := change.Value.(bool); ok {
+					logrus.WithField("mac", r.state.mac).WithField("discovering", discovering).Debug("adapter discovery state changed")
+				}
+			}
+		}
+	}
+}
+
+// recoverAdapter re-acquires the default adapter, optionally power-cycling
+// it through btmgmt first, and retries until it succeeds or ctx is
+// cancelled.
+func (r *Reconnector) recoverAdapter(ctx context.Context) error {
+	logrus.Warn("adapter unavailable, attempting recovery")
+
+	if r.cli.powerCycleAdapter {
+		if err := powerCycleAdapter(r.cli.adapter); err != nil {
+			logrus.WithError(err).Warn("power-cycle adapter via btmgmt")
+		}
+	}
+
+	const retryInterval = 2 * time.Second
+	for {
+		a, err := adapter.GetDefaultAdapter()
+		if err == nil {
+			r.cli.adapter = a
+			logrus.Info("adapter recovered")
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// powerCycleAdapter power-cycles a, shelling out to the btmgmt tool rather
+// than going through BlueZ, since an adapter that needs recovering may not
+// be responding over DBus at all.
+func powerCycleAdapter(a *adapter.Adapter1) error {
+	id, err := a.GetAdapterID()
+	if err != nil {
+		return fmt.Errorf("get adapter id: %w", err)
+	}
+
+	if out, err := exec.Command("btmgmt", "-i", id, "power", "off").CombinedOutput(); err != nil {
+		return fmt.Errorf("btmgmt power off: %w: %s", err, out)
+	}
+	if out, err := exec.Command("btmgmt", "-i", id, "power", "on").CombinedOutput(); err != nil {
+		return fmt.Errorf("btmgmt power on: %w: %s", err, out)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/muka/go-bluetooth/bluez/profile/adapter"
+	cliapp "github.com/urfave/cli/v2"
+)
+
+type scanFlags struct {
+	Duration  time.Duration
+	RSSIMin   int
+	NameRegex string
+	Services  cliapp.StringSlice
+	JSON      bool
+}
+
+// scanCommand lists nearby devices, updating a live table in place, or
+// streaming one JSON object per device event with --json.
+func (cli *Bctl) scanCommand() *cliapp.Command {
+	f := scanFlags{}
+
+	return &cliapp.Command{
+		Name:  "scan",
+		Usage: "scan for nearby bluetooth devices",
+		Flags: []cliapp.Flag{
+			&cliapp.DurationFlag{
+				Name:        "duration",
+				Usage:       "how long to scan for, 0 means until interrupted",
+				Destination: &f.Duration,
+			},
+			&cliapp.IntFlag{
+				Name:        "rssi-min",
+				Usage:       "hide devices with RSSI below this threshold",
+				Value:       -127,
+				Destination: &f.RSSIMin,
+			},
+			&cliapp.StringFlag{
+				Name:        "name-regex",
+				Usage:       "only show devices whose name matches this regular expression",
+				Destination: &f.NameRegex,
+			},
+			&cliapp.StringSliceFlag{
+				Name:        "service",
+				Usage:       "only discover devices advertising this service UUID (repeatable); devices already cached by BlueZ are listed without this check",
+				Destination: &f.Services,
+			},
+			&cliapp.BoolFlag{
+				Name:        "json",
+				Usage:       "print one JSON object per device event instead of a live table",
+				Destination: &f.JSON,
+			},
+		},
+		Action: func(ctx *cliapp.Context) error {
+			if err := cli.Init(); err != nil {
+				return err
+			}
+			defer cli.unregisterAgent()
+
+			return cli.scan(ctx.Context, f)
+		},
+	}
+}
+
+func (cli *Bctl) scan(ctx context.Context, f scanFlags) error {
+	var nameFilter *regexp.Regexp
+	if f.NameRegex != "" {
+		re, err := regexp.Compile(f.NameRegex)
+		if err != nil {
+			return fmt.Errorf("compile --name-regex: %w", err)
+		}
+		nameFilter = re
+	}
+
+	filter := adapter.NewDiscoveryFilter()
+	filter.RSSI = int16(f.RSSIMin)
+	filter.UUIDs = f.Services.Value()
+
+	if f.Duration > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, f.Duration)
+		defer cancelTimeout()
+	}
+
+	events, cancel, err := cli.watchDevices(&filter)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	table := newScanTable()
+
+	cached, err := cli.adapter.GetDevices()
+	if err == nil {
+		for _, d := range cached {
+			ev, err := deviceEvent(d)
+			if err != nil {
+				continue
+			}
+			if !matchesScanFilter(ev, f.RSSIMin, nameFilter) {
+				continue
+			}
+			if f.JSON {
+				printScanEvent(ev)
+			} else {
+				table.upsert(ev)
+			}
+		}
+	}
+
+	if !f.JSON {
+		table.render()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-events:
+			if !ok {
+				return nil
+			}
+
+			if ev.Kind == DeviceLost {
+				if f.JSON {
+					printScanEvent(ev)
+					continue
+				}
+				table.remove(ev.Path)
+				table.render()
+				continue
+			}
+
+			if !matchesScanFilter(ev, f.RSSIMin, nameFilter) {
+				continue
+			}
+
+			if f.JSON {
+				printScanEvent(ev)
+				continue
+			}
+
+			table.upsert(ev)
+			table.render()
+		}
+	}
+}
+
+func matchesScanFilter(ev DeviceEvent, rssiMin int, nameFilter *regexp.Regexp) bool {
+	if int(ev.RSSI) < rssiMin {
+		return false
+	}
+	if nameFilter != nil && !nameFilter.MatchString(ev.Name) {
+		return false
+	}
+	return true
+}
+
+func printScanEvent(ev DeviceEvent) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	fmt.Println(string(data))
+}
+
+// scanTable renders the devices seen so far as a table that is repainted
+// in place on every update.
+type scanTable struct {
+	rows      map[dbus.ObjectPath]DeviceEvent
+	lastLines int
+}
+
+func newScanTable() *scanTable {
+	return &scanTable{rows: make(map[dbus.ObjectPath]DeviceEvent)}
+}
+
+func (t *scanTable) upsert(ev DeviceEvent) {
+	t.rows[ev.Path] = ev
+}
+
+func (t *scanTable) remove(path dbus.ObjectPath) {
+	delete(t.rows, path)
+}
+
+func (t *scanTable) render() {
+	paths := make([]dbus.ObjectPath, 0, len(t.rows))
+	for path := range t.rows {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i] < paths[j] })
+
+	if t.lastLines > 0 {
+		fmt.Printf("\033[%dA\033[J", t.lastLines)
+	}
+
+	fmt.Printf("%-20s %-25s %6s %s\n", "ADDRESS", "NAME", "RSSI", "SERVICES")
+	for _, path := range paths {
+		ev := t.rows[path]
+		fmt.Printf("%-20s %-25s %6d %s\n", ev.Address, ev.Name, ev.RSSI, strings.Join(ev.Services, ","))
+	}
+	t.lastLines = len(paths) + 1
+}
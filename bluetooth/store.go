@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// rssiHistoryLimit bounds RSSIHistory to a ring buffer of roughly this many
+// samples.
+const rssiHistoryLimit = 128
+
+// saveDebounce bounds how often Upsert persists to disk: a live scan/watch
+// session can see many advertisements per second, and round-tripping the
+// whole store through json.MarshalIndent + os.WriteFile on every one of
+// them would serialize discovery behind disk I/O.
+const saveDebounce = 500 * time.Millisecond
+
+// RSSISample is a single RSSI observation at a point in time.
+type RSSISample struct {
+	RSSI int16     `json:"rssi"`
+	At   time.Time `json:"at"`
+}
+
+// KnownDevice is everything the store remembers about a device seen during
+// discovery.
+type KnownDevice struct {
+	MAC          string                 `json:"mac"`
+	Name         string                 `json:"name,omitempty"`
+	Manufacturer map[uint16]interface{} `json:"manufacturer,omitempty"`
+	Services     []string               `json:"services,omitempty"`
+	FirstSeen    time.Time              `json:"first_seen"`
+	LastSeen     time.Time              `json:"last_seen"`
+	// RSSIHistory is a bounded ring buffer of the last rssiHistoryLimit
+	// RSSI samples, oldest first.
+	RSSIHistory []RSSISample `json:"rssi_history,omitempty"`
+}
+
+// Store is an on-disk database of every device seen during discovery,
+// persisted as a single JSON file under defaultStorePath (or --store-path).
+type Store struct {
+	path string
+
+	mu        sync.Mutex
+	devices   map[string]*KnownDevice
+	dirty     bool
+	saveTimer *time.Timer
+}
+
+// defaultStorePath returns $XDG_DATA_HOME/bctl/devices.json, falling back
+// to ~/.local/share/bctl/devices.json.
+func defaultStorePath() (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("get user home dir: %w", err)
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dataHome, "bctl", "devices.json"), nil
+}
+
+// openStore loads the device database at path, or starts an empty one if
+// it doesn't exist yet.
+func openStore(path string) (*Store, error) {
+	s := &Store{path: path, devices: make(map[string]*KnownDevice)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("read device store %s: %w", path, err)
+	}
+
+	var devices []*KnownDevice
+	if err := json.Unmarshal(data, &devices); err != nil {
+		return nil, fmt.Errorf("parse device store %s: %w", path, err)
+	}
+	for _, d := range devices {
+		s.devices[d.MAC] = d
+	}
+	return s, nil
+}
+
+// save writes the whole database back to disk, via a temp file + rename so
+// a crash mid-write can't corrupt it.
+func (s *Store) save() error {
+	s.mu.Lock()
+	devices := make([]*KnownDevice, 0, len(s.devices))
+	for _, d := range s.devices {
+		devices = append(devices, d)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(devices, func(i, j int) bool { return devices[i].MAC < devices[j].MAC })
+
+	data, err := json.MarshalIndent(devices, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal device store: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("create device store directory: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write device store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("replace device store: %w", err)
+	}
+	return nil
+}
+
+// Upsert records a fresh observation of a device from a discovery event,
+// appending to its RSSI history, and schedules a debounced save rather than
+// persisting immediately (see saveDebounce).
+func (s *Store) Upsert(ev DeviceEvent) error {
+	now := time.Now()
+
+	s.mu.Lock()
+	d, ok := s.devices[ev.Address]
+	if !ok {
+		d = &KnownDevice{MAC: ev.Address, FirstSeen: now}
+		s.devices[ev.Address] = d
+	}
+	if ev.Name != "" {
+		d.Name = ev.Name
+	}
+	if len(ev.Services) > 0 {
+		d.Services = ev.Services
+	}
+	if len(ev.ManufacturerData) > 0 {
+		d.Manufacturer = ev.ManufacturerData
+	}
+	d.LastSeen = now
+	d.RSSIHistory = append(d.RSSIHistory, RSSISample{RSSI: ev.RSSI, At: now})
+	if len(d.RSSIHistory) > rssiHistoryLimit {
+		d.RSSIHistory = d.RSSIHistory[len(d.RSSIHistory)-rssiHistoryLimit:]
+	}
+	s.dirty = true
+	if s.saveTimer == nil {
+		s.saveTimer = time.AfterFunc(saveDebounce, s.flush)
+	}
+	s.mu.Unlock()
+
+	return nil
+}
+
+// flush persists the store if Upsert marked it dirty since the last save.
+// It runs off s.saveTimer, so it never runs more often than saveDebounce.
+func (s *Store) flush() {
+	s.mu.Lock()
+	if !s.dirty {
+		s.saveTimer = nil
+		s.mu.Unlock()
+		return
+	}
+	s.dirty = false
+	s.saveTimer = nil
+	s.mu.Unlock()
+
+	if err := s.save(); err != nil {
+		logrus.WithError(err).Warn("persist device store")
+	}
+}
+
+// List returns every known device, sorted by MAC.
+func (s *Store) List() []KnownDevice {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	devices := make([]KnownDevice, 0, len(s.devices))
+	for _, d := range s.devices {
+		devices = append(devices, *d)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].MAC < devices[j].MAC })
+	return devices
+}
+
+// Get returns the known device for mac, if any.
+func (s *Store) Get(mac string) (KnownDevice, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.devices[mac]
+	if !ok {
+		return KnownDevice{}, false
+	}
+	return *d, true
+}
+
+// Forget removes mac from the database and persists the change.
+func (s *Store) Forget(mac string) error {
+	s.mu.Lock()
+	_, ok := s.devices[mac]
+	delete(s.devices, mac)
+	s.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("device %s not known", mac)
+	}
+	return s.save()
+}
+
+// FindByName returns every known device whose name contains substr,
+// case-insensitively.
+func (s *Store) FindByName(substr string) []KnownDevice {
+	substr = strings.ToLower(substr)
+
+	var matches []KnownDevice
+	for _, d := range s.List() {
+		if strings.Contains(strings.ToLower(d.Name), substr) {
+			matches = append(matches, d)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	cliapp "github.com/urfave/cli/v2"
+)
+
+// watchCommand connects to every --mac target and then stays running,
+// reconnecting on disconnect and surviving adapter resets (rfkill,
+// suspend/resume) until interrupted.
+func (cli *Bctl) watchCommand() *cliapp.Command {
+	var (
+		minBackoff time.Duration
+		maxBackoff time.Duration
+		jitter     float64
+	)
+
+	return &cliapp.Command{
+		Name:  "watch",
+		Usage: "connect and stay connected, auto-reconnecting on disconnect or adapter reset",
+		Flags: []cliapp.Flag{
+			&cliapp.DurationFlag{
+				Name:        "min-backoff",
+				Usage:       "initial delay before a reconnect attempt",
+				Value:       1 * time.Second,
+				Destination: &minBackoff,
+			},
+			&cliapp.DurationFlag{
+				Name:        "max-backoff",
+				Usage:       "maximum delay between reconnect attempts",
+				Value:       30 * time.Second,
+				Destination: &maxBackoff,
+			},
+			&cliapp.Float64Flag{
+				Name:        "jitter",
+				Usage:       "fraction of the backoff to add as random jitter (0-1)",
+				Value:       0.2,
+				Destination: &jitter,
+			},
+			&cliapp.BoolFlag{
+				Name:        "power-cycle-adapter",
+				Usage:       "power-cycle the adapter via btmgmt when it becomes unavailable",
+				Destination: &cli.powerCycleAdapter,
+			},
+		},
+		Action: func(ctx *cliapp.Context) error {
+			if err := cli.Init(); err != nil {
+				return err
+			}
+			defer cli.unregisterAgent()
+
+			cancel, err := cli.Discover(ctx)
+			if err != nil {
+				return err
+			}
+			defer cancel()
+			if err := cli.Wait(ctx.Context); err != nil {
+				return err
+			}
+
+			if err := cli.Connect(ctx); err != nil {
+				return err
+			}
+
+			var wg sync.WaitGroup
+			errs := make(chan error, len(cli.targets))
+			for _, state := range cli.targets {
+				wg.Add(1)
+				go func(state *deviceState) {
+					defer wg.Done()
+					r := newReconnector(cli, state, minBackoff, maxBackoff, jitter)
+					if err := r.Run(ctx.Context); err != nil {
+						errs <- err
+					}
+				}(state)
+			}
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}